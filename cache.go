@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Cache[K comparable, V any] interface {
-	Get(K) V
+	Get(K) (V, error)
+	GetContext(context.Context, K) (V, error)
 	Put(K, V)
 }
 
@@ -19,75 +22,245 @@ func (this *CacheValue[V]) Expired() bool {
 	return this.Expiration.Before(time.Now())
 }
 
+// pendingLoad tracks an in-flight (or recently-failed) loadingFunc call for a
+// single key. It never touches the Store: a Store only ever holds
+// successfully loaded values, so the bookkeeping needed to make concurrent
+// callers share one load - or share one negatively-cached error - lives here
+// instead.
+type pendingLoad[V any] struct {
+	wg        sync.WaitGroup
+	ready     bool
+	err       error
+	expiresAt time.Time // only meaningful when ready && err != nil
+}
+
+func (this *pendingLoad[V]) negativeCacheExpired() bool {
+	return this.expiresAt.Before(time.Now())
+}
+
 type loadingCache[K comparable, V any] struct {
-	dataMap       map[K]*CacheValue[V]
-	lockMap       map[K]*sync.Mutex
-	lockMapLock   *sync.Mutex
-	loadingFunc   func(K) V
-	cacheDuration time.Duration
+	mu                    sync.Mutex
+	pending               map[K]*pendingLoad[V]
+	store                 Store[K, V]
+	loadingFunc           func(context.Context, K) (V, error)
+	cacheDuration         time.Duration
+	negativeCacheDuration time.Duration
+	stats                 cacheStats
+	onEvent               func(Event)
 }
 
-func NewLoadingCache[K comparable, V any](loadingFunc func(K) V, cacheDuration time.Duration) Cache[K, V] {
+// NewLoadingCache returns a Cache that loads values using loadingFunc the first
+// time a key is requested (or after cacheDuration has elapsed) and shares that
+// single load across every concurrent caller asking for the same key.
+//
+// Errors returned by loadingFunc are never cached and are propagated to every
+// caller that was waiting on the load; use NewLoadingCacheWithNegativeTTL to
+// cache errors for a short duration instead.
+func NewLoadingCache[K comparable, V any](loadingFunc func(context.Context, K) (V, error), cacheDuration time.Duration) Cache[K, V] {
+	return NewLoadingCacheWithStore[K, V](loadingFunc, cacheDuration, newMemoryStore[K, V]())
+}
+
+// NewLoadingCacheWithNegativeTTL is identical to NewLoadingCache except that a
+// loadingFunc error is cached for negativeCacheDuration, so a key that is
+// failing to load does not cause every caller to hammer loadingFunc.
+func NewLoadingCacheWithNegativeTTL[K comparable, V any](loadingFunc func(context.Context, K) (V, error), cacheDuration time.Duration, negativeCacheDuration time.Duration) Cache[K, V] {
+	cache := NewLoadingCacheWithStore[K, V](loadingFunc, cacheDuration, newMemoryStore[K, V]()).(*loadingCache[K, V])
+	cache.negativeCacheDuration = negativeCacheDuration
+	return cache
+}
+
+// NewLoadingCacheWithStore is NewLoadingCache with an explicit Store, for
+// plugging in a Redis- or file-backed one instead of the in-memory default
+// while keeping the same loading/singleflight behavior.
+func NewLoadingCacheWithStore[K comparable, V any](loadingFunc func(context.Context, K) (V, error), cacheDuration time.Duration, store Store[K, V]) Cache[K, V] {
 	return &loadingCache[K, V]{
-		dataMap:       map[K]*CacheValue[V]{},
-		lockMap:       map[K]*sync.Mutex{},
-		lockMapLock:   &sync.Mutex{},
+		pending:       map[K]*pendingLoad[V]{},
+		store:         store,
 		loadingFunc:   loadingFunc,
 		cacheDuration: cacheDuration,
 	}
 }
 
-func (l loadingCache[K, V]) Get(key K) V {
-	keyLock := l.getKeyLockFromMap(key)
+func (l *loadingCache[K, V]) Get(key K) (V, error) {
+	return l.GetContext(context.Background(), key)
+}
+
+func (l *loadingCache[K, V]) GetContext(ctx context.Context, key K) (V, error) {
+	for {
+		l.mu.Lock()
+
+		wasExpired := false
+		if value, exists := l.store.Get(key); exists {
+			if !value.Expired() {
+				l.mu.Unlock()
+				atomic.AddUint64(&l.stats.hits, 1)
+				l.emit(Event{Type: EventHit, Key: key})
+				return value.Value, nil
+			}
+			wasExpired = true
+		}
+
+		if p, exists := l.pending[key]; exists {
+			if p.ready {
+				// a negatively-cached error, still within its TTL
+				if !p.negativeCacheExpired() {
+					err := p.err
+					l.mu.Unlock()
+					atomic.AddUint64(&l.stats.hits, 1)
+					l.emit(Event{Type: EventHit, Key: key})
+					var zero V
+					return zero, err
+				}
+				delete(l.pending, key)
+			} else {
+				// a load for this key is already in flight
+				l.mu.Unlock()
+				if err := waitContext(ctx, &p.wg); err != nil {
+					var zero V
+					return zero, err
+				}
+				if p.err != nil {
+					// the shared load failed: hand its error to this waiter too
+					var zero V
+					return zero, p.err
+				}
+				continue
+			}
+		}
+
+		missEvent := EventMiss
+		if wasExpired {
+			missEvent = EventExpire
+		}
+
+		// no value, an expired one, or an expired negative cache: claim the
+		// key by installing a placeholder, then release the lock before
+		// running the (potentially slow) loader
+		p := &pendingLoad[V]{}
+		p.wg.Add(1)
+		l.pending[key] = p
+		l.mu.Unlock()
+
+		if wasExpired {
+			atomic.AddUint64(&l.stats.expirations, 1)
+		} else {
+			atomic.AddUint64(&l.stats.misses, 1)
+		}
+		l.emit(Event{Type: missEvent, Key: key})
+
+		loadStart := time.Now()
+		value, err := l.loadingFunc(ctx, key)
+		duration := time.Since(loadStart)
 
-	// try to claim read lock
-	keyLock.Lock()
-	defer keyLock.Unlock()
+		atomic.AddUint64(&l.stats.loads, 1)
+		l.stats.loadTimes.observe(duration)
+		l.emit(Event{Type: EventLoad, Key: key, Duration: duration})
 
-	// if no item in the map load items, put and then return
-	// only blocking operation in the map
-	value, exists := l.dataMap[key]
+		l.mu.Lock()
+		if err == nil {
+			l.store.Set(key, CacheValue[V]{
+				Value:      value,
+				Expiration: time.Now().Add(l.cacheDuration),
+			})
+			delete(l.pending, key)
+		} else if l.negativeCacheDuration > 0 {
+			p.expiresAt = time.Now().Add(l.negativeCacheDuration)
+		} else {
+			// don't cache the error, let the next caller retry the load
+			delete(l.pending, key)
+		}
+		p.err = err
+		p.ready = true
+		l.mu.Unlock()
+		p.wg.Done()
 
-	// if not exists load OR value does exist but is expired
-	if !exists || (exists && value.Expired()) {
-		newValue := l.loadingFunc(key)
-		// put new data in map
-		l.dataMap[key] = &CacheValue[V]{
-			Value:      newValue,
-			Expiration: time.Now().Add(l.cacheDuration),
+		if err != nil {
+			atomic.AddUint64(&l.stats.loadErrors, 1)
+			l.emit(Event{Type: EventLoadError, Key: key, Duration: duration})
 		}
-		return newValue
-	} else {
-		// else we have a valid value and can return
-		return value.Value
+
+		return value, err
 	}
 }
 
-func (l loadingCache[K, V]) Put(key K, value V) {
-	keyLock := l.getKeyLockFromMap(key)
-	keyLock.Lock()
-	defer keyLock.Unlock()
-
-	// put new data in map
-	l.dataMap[key] = &CacheValue[V]{
+func (l *loadingCache[K, V]) Put(key K, value V) {
+	l.mu.Lock()
+	l.store.Set(key, CacheValue[V]{
 		Value:      value,
 		Expiration: time.Now().Add(l.cacheDuration),
+	})
+	delete(l.pending, key)
+	l.mu.Unlock()
+
+	atomic.AddUint64(&l.stats.puts, 1)
+	l.emit(Event{Type: EventPut, Key: key})
+}
+
+// OnEvent registers a hook called for every Hit, Miss, Expire, Load,
+// LoadError and Put. It replaces any previously registered hook.
+func (l *loadingCache[K, V]) OnEvent(onEvent func(Event)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onEvent = onEvent
+}
+
+func (l *loadingCache[K, V]) emit(event Event) {
+	if l.onEvent != nil {
+		l.onEvent(event)
 	}
 }
 
-/**
-Claims the lockMapLock which is responsible for making sure there are no race conditions in creating keyLocks
-tries to fetch lock from map, if none exists creates, puts in map and returns
-*/
-func (this *loadingCache[K, V]) getKeyLockFromMap(key K) *sync.Mutex {
-	this.lockMapLock.Lock()
-	defer this.lockMapLock.Unlock()
-
-	if lock, exists := this.lockMap[key]; exists {
-		return lock
-	} else {
-		newLock := &sync.Mutex{}
-		this.lockMap[key] = newLock
-		return newLock
+// recordEviction lets a Store that actually evicts entries (e.g. lruStore)
+// feed that into this cache's shared Stats/OnEvent machinery, so Stats() and
+// OnEvent reflect reality instead of only ever seeing Hit/Miss/Load from
+// GetContext. EvictReasonRemoved is not counted: it's an explicit Remove
+// call, not something a caller needs surfaced as cache pressure.
+func (l *loadingCache[K, V]) recordEviction(reason EvictReason, key K) {
+	switch reason {
+	case EvictReasonEvicted:
+		atomic.AddUint64(&l.stats.evictions, 1)
+		l.emit(Event{Type: EventEvict, Key: key})
+	case EvictReasonExpired:
+		atomic.AddUint64(&l.stats.expirations, 1)
+		l.emit(Event{Type: EventExpire, Key: key})
+	}
+}
+
+// Stats returns a point-in-time snapshot of the cache's counters.
+func (l *loadingCache[K, V]) Stats() Stats {
+	size := 0
+	l.store.Iterate(func(K, CacheValue[V]) bool {
+		size++
+		return true
+	})
+
+	return Stats{
+		Hits:                atomic.LoadUint64(&l.stats.hits),
+		Misses:              atomic.LoadUint64(&l.stats.misses),
+		Expirations:         atomic.LoadUint64(&l.stats.expirations),
+		Loads:               atomic.LoadUint64(&l.stats.loads),
+		LoadErrors:          atomic.LoadUint64(&l.stats.loadErrors),
+		Evictions:           atomic.LoadUint64(&l.stats.evictions),
+		Puts:                atomic.LoadUint64(&l.stats.puts),
+		Size:                size,
+		LoadDurationBuckets: l.stats.loadTimes.snapshot(),
+	}
+}
+
+// waitContext waits for wg to finish, returning early with ctx.Err() if ctx is
+// canceled first. The spawned goroutine is not leaked: it exits as soon as wg
+// finishes, it just may outlive waitContext itself if ctx wins the race.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
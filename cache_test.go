@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCacheSingleFlight(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	started := make(chan struct{}, 100)
+
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		atomic.AddInt64(&calls, 1)
+		started <- struct{}{}
+		<-release
+		return 42, nil
+	}, time.Minute)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make([]int, callers)
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			value, err := cache.Get("k")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = value
+		}(i)
+	}
+
+	<-started // at least one caller has entered loadingFunc
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("loadingFunc called %d times, want 1", got)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Fatalf("results[%d] = %d, want 42", i, v)
+		}
+	}
+}
+
+func TestLoadingCacheErrorPropagation(t *testing.T) {
+	wantErr := errors.New("boom")
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return 0, wantErr
+	}, time.Minute)
+
+	_, err := cache.Get("k")
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Get err = %v, want %v", err, wantErr)
+	}
+
+	// without negative caching, the next Get retries the loader rather than
+	// replaying the cached error forever
+	calls := 0
+	cache2 := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		calls++
+		return 0, wantErr
+	}, time.Minute)
+	cache2.Get("k")
+	cache2.Get("k")
+	if calls != 2 {
+		t.Fatalf("loadingFunc called %d times without negative caching, want 2", calls)
+	}
+}
+
+func TestLoadingCacheNegativeTTL(t *testing.T) {
+	wantErr := errors.New("boom")
+	var calls int64
+	cache := NewLoadingCacheWithNegativeTTL[string, int](func(ctx context.Context, key string) (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return 0, wantErr
+	}, time.Minute, 50*time.Millisecond)
+
+	_, err := cache.Get("k")
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Get err = %v, want %v", err, wantErr)
+	}
+	_, err = cache.Get("k")
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Get err = %v, want %v", err, wantErr)
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("loadingFunc called %d times within negative TTL, want 1", got)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	cache.Get("k")
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Fatalf("loadingFunc called %d times after negative TTL expired, want 2", got)
+	}
+}
+
+func TestLoadingCacheGetContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		<-release
+		return 1, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cache.Get("k") // becomes the in-flight loader
+	}()
+	time.Sleep(20 * time.Millisecond) // let the first caller install the placeholder
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.GetContext(ctx, "k")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext err = %v, want context.DeadlineExceeded", err)
+	}
+
+	release <- struct{}{}
+	wg.Wait()
+}
+
+func TestLoadingCachePutOverridesLoader(t *testing.T) {
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return -1, nil
+	}, time.Minute)
+
+	cache.Put("k", 7)
+
+	value, err := cache.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != 7 {
+		t.Fatalf("Get() = %d, want 7 (from Put, not loadingFunc)", value)
+	}
+}
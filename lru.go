@@ -0,0 +1,313 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// EvictReason describes why an entry left an LRU cache, passed to the
+// optional OnEvict callback.
+type EvictReason int
+
+const (
+	// EvictReasonExpired means the entry's ttl elapsed before it was read.
+	EvictReasonExpired EvictReason = iota
+	// EvictReasonEvicted means the entry was pushed out to make room for a
+	// new one once the cache reached maxEntries.
+	EvictReasonEvicted
+	// EvictReasonRemoved means the entry was deleted explicitly via Remove.
+	// Purge only removes expired entries, reported as EvictReasonExpired.
+	EvictReasonRemoved
+)
+
+type lruElement[K comparable, V any] struct {
+	key   K
+	value CacheValue[V]
+}
+
+// evictionNotice is a removal lruStore has recorded but not yet told anyone
+// about. Notices are buffered while s.mu (and, for a Set coming from
+// loadingCache.Put/GetContext, l.mu too) is held, and only handed to
+// lruLoadingCache via drainEvictions once every lock involved has been
+// released - see the lruStore comment on why removeElementLocked doesn't just
+// call onEvict directly.
+type evictionNotice[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// lruStore is a Store[K, V] bounded to at most maxEntries items, in the style
+// of hashicorp/golang-lru's expirable LRU: entries are kept in a
+// doubly-linked list ordered by recency so Get/Set can move the touched entry
+// to the front in O(1), and the least-recently-used entry is evicted once the
+// store is full. It is the Store lruLoadingCache hands to loadingCache, so
+// that loadingCache's existing singleflight GetContext is what actually
+// guards concurrent loads - lruStore itself only ever sees a load's already
+// settled result.
+//
+// Besides the maxEntries bound, Set also lazily sweeps a few expired entries
+// off the back of the list on every write (see purgeExpiredLocked), so a key
+// that is never read again after expiring doesn't sit around occupying a slot
+// indefinitely - it's cleared out by whatever writes happen to come after it
+// instead of requiring a caller to remember to call Purge.
+//
+// lruStore never invokes a callback itself: Set/Delete run while
+// loadingCache.mu may also be held (Set is called from inside
+// loadingCache.Put/GetContext), so calling into user code here could
+// deadlock a callback that re-enters the same cache. Evictions are buffered
+// in pending instead, and lruLoadingCache drains and fires them once its own
+// call into loadingCache has returned and every lock is released.
+type lruStore[K comparable, V any] struct {
+	mu         sync.Mutex
+	ll         *list.List
+	items      map[K]*list.Element
+	maxEntries int
+	onEvict    func(K, V, EvictReason)
+	pending    []evictionNotice[K, V]
+
+	// reportEviction, when set, feeds every eviction into the composing
+	// loadingCache's Stats/OnEvent machinery (see recordEviction in
+	// cache.go), so lruLoadingCache's eviction behavior isn't invisible to
+	// Stats().Evictions and OnEvent the way it would be if the list+map
+	// stayed a purely private bookkeeping detail of this Store. Called from
+	// lruLoadingCache.fireEvictions for each notice drainEvictions returns,
+	// never from inside removeElementLocked.
+	reportEviction func(EvictReason, K)
+}
+
+func newLRUStore[K comparable, V any](maxEntries int) *lruStore[K, V] {
+	return &lruStore[K, V]{
+		ll:         list.New(),
+		items:      map[K]*list.Element{},
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *lruStore[K, V]) Get(key K) (CacheValue[V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exists := s.items[key]
+	if !exists {
+		return CacheValue[V]{}, false
+	}
+	s.ll.MoveToFront(elem)
+	return elem.Value.(*lruElement[K, V]).value, true
+}
+
+func (s *lruStore[K, V]) Set(key K, value CacheValue[V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, exists := s.items[key]; exists {
+		elem.Value.(*lruElement[K, V]).value = value
+		s.ll.MoveToFront(elem)
+		return
+	}
+
+	elem := s.ll.PushFront(&lruElement[K, V]{key: key, value: value})
+	s.items[key] = elem
+
+	s.purgeExpiredLocked(maxLazyPurgePerWrite)
+
+	if s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		if oldest := s.ll.Back(); oldest != nil {
+			s.removeElementLocked(oldest, EvictReasonEvicted)
+		}
+	}
+}
+
+// maxLazyPurgePerWrite bounds how many entries purgeExpiredLocked examines
+// (and so how many expired ones it sweeps) per Set, so a write stays
+// O(1)-ish even if the store has
+// accumulated a long backlog of expired, unread keys.
+const maxLazyPurgePerWrite = 4
+
+// purgeExpiredLocked examines up to limit entries and removes the expired
+// ones among them, scanning from the back of the list (the
+// least-recently-touched end) so it finds idle expired keys without having to
+// walk the whole store on every write. Callers must hold s.mu.
+func (s *lruStore[K, V]) purgeExpiredLocked(limit int) {
+	examined := 0
+	for elem := s.ll.Back(); elem != nil && examined < limit; examined++ {
+		prev := elem.Prev()
+		entry := elem.Value.(*lruElement[K, V])
+		if entry.value.Expired() {
+			s.removeElementLocked(elem, EvictReasonExpired)
+		}
+		elem = prev
+	}
+}
+
+func (s *lruStore[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, exists := s.items[key]; exists {
+		s.removeElementLocked(elem, EvictReasonRemoved)
+	}
+}
+
+func (s *lruStore[K, V]) Iterate(fn func(K, CacheValue[V]) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for elem := s.ll.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*lruElement[K, V])
+		if !fn(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+func (s *lruStore[K, V]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// Purge scans the store and removes every expired entry, so that idle keys do
+// not keep occupying a slot until they happen to be read again. Set already
+// does this lazily, bounded to maxLazyPurgePerWrite entries per write; Purge
+// is for a caller that wants the backlog cleared immediately instead of
+// waiting for it to drain across future writes.
+func (s *lruStore[K, V]) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpiredLocked(s.ll.Len())
+}
+
+// removeElementLocked removes elem from both the list and the map and
+// buffers an evictionNotice for lruLoadingCache to fire once unlocked.
+// Callers must hold s.mu.
+func (s *lruStore[K, V]) removeElementLocked(elem *list.Element, reason EvictReason) {
+	entry := elem.Value.(*lruElement[K, V])
+	s.ll.Remove(elem)
+	delete(s.items, entry.key)
+
+	s.pending = append(s.pending, evictionNotice[K, V]{
+		key:    entry.key,
+		value:  entry.value.Value,
+		reason: reason,
+	})
+}
+
+// drainEvictions returns every evictionNotice buffered since the last call
+// and clears the buffer. Callers must not hold s.mu.
+func (s *lruStore[K, V]) drainEvictions() []evictionNotice[K, V] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	notices := s.pending
+	s.pending = nil
+	return notices
+}
+
+func (s *lruStore[K, V]) getOnEvict() func(K, V, EvictReason) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.onEvict
+}
+
+// lruLoadingCache is a Cache[K, V] bounded to at most maxEntries items. It
+// composes a *loadingCache, the same singleflight-loading implementation
+// NewLoadingCache uses, over an lruStore - so concurrent callers missing on
+// the same key share one loadingFunc call exactly like the unbounded cache
+// does, instead of each racing their own call the way a standalone map+lock
+// implementation would.
+type lruLoadingCache[K comparable, V any] struct {
+	*loadingCache[K, V]
+	store *lruStore[K, V]
+}
+
+// NewLRULoadingCache returns a Cache[K, V] that holds at most maxEntries
+// entries, each expiring ttl after it was last written. When a Put or a
+// loadingFunc call on a miss would exceed maxEntries, the least-recently-used
+// entry is evicted to make room.
+//
+// Use OnEvict on the returned cache (via its concrete type) if you need to
+// know why an entry left, e.g. to close a resource it holds.
+func NewLRULoadingCache[K comparable, V any](loadingFunc func(context.Context, K) (V, error), maxEntries int, ttl time.Duration) *lruLoadingCache[K, V] {
+	store := newLRUStore[K, V](maxEntries)
+	inner := NewLoadingCacheWithStore[K, V](loadingFunc, ttl, store).(*loadingCache[K, V])
+	store.reportEviction = inner.recordEviction
+
+	return &lruLoadingCache[K, V]{
+		loadingCache: inner,
+		store:        store,
+	}
+}
+
+// fireEvictions drains whatever evictions lruStore has buffered and invokes
+// OnEvict/OnEvent for each. Callers must call it only after their own call
+// into l.loadingCache or l.store has returned, so that l.mu and l.store.mu
+// are both free - letting a callback call back into this same cache (e.g.
+// Put the evicted value into an L2 cache) without deadlocking.
+func (l *lruLoadingCache[K, V]) fireEvictions() {
+	notices := l.store.drainEvictions()
+	if len(notices) == 0 {
+		return
+	}
+
+	onEvict := l.store.getOnEvict()
+	for _, n := range notices {
+		if onEvict != nil {
+			onEvict(n.key, n.value, n.reason)
+		}
+		if l.store.reportEviction != nil {
+			l.store.reportEviction(n.reason, n.key)
+		}
+	}
+}
+
+func (l *lruLoadingCache[K, V]) Get(key K) (V, error) {
+	value, err := l.loadingCache.Get(key)
+	l.fireEvictions()
+	return value, err
+}
+
+func (l *lruLoadingCache[K, V]) GetContext(ctx context.Context, key K) (V, error) {
+	value, err := l.loadingCache.GetContext(ctx, key)
+	l.fireEvictions()
+	return value, err
+}
+
+func (l *lruLoadingCache[K, V]) Put(key K, value V) {
+	l.loadingCache.Put(key, value)
+	l.fireEvictions()
+}
+
+// OnEvict registers a callback invoked whenever an entry leaves the cache,
+// whether by expiry, LRU eviction, or explicit removal. It replaces any
+// previously registered callback.
+func (l *lruLoadingCache[K, V]) OnEvict(onEvict func(K, V, EvictReason)) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.onEvict = onEvict
+}
+
+// Len returns the number of entries currently held, including ones that have
+// expired but have not yet been purged.
+func (l *lruLoadingCache[K, V]) Len() int {
+	return l.store.Len()
+}
+
+// Remove deletes key from the cache, invoking OnEvict with
+// EvictReasonRemoved if it was present.
+func (l *lruLoadingCache[K, V]) Remove(key K) {
+	l.store.Delete(key)
+	l.fireEvictions()
+}
+
+// Purge scans the cache and removes every expired entry, so that idle keys do
+// not keep occupying a slot until they happen to be read again.
+func (l *lruLoadingCache[K, V]) Purge() {
+	l.store.Purge()
+	l.fireEvictions()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}, 2, time.Hour)
+
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Get(1) // touch 1 so 2 becomes the least-recently-used
+	cache.Put(3, 3)
+
+	if got := cache.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+
+	loads := 0
+	loaderCache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+		loads++
+		return key, nil
+	}, 2, time.Hour)
+	loaderCache.Put(1, 1)
+	loaderCache.Put(2, 2)
+	loaderCache.Get(1)
+	loaderCache.Put(3, 3) // should evict 2, not 1
+
+	loaderCache.Get(1) // still cached
+	if loads != 0 {
+		t.Fatalf("key 1 should still be cached, got %d loads", loads)
+	}
+	loaderCache.Get(2) // evicted, should reload
+	if loads != 1 {
+		t.Fatalf("key 2 should have been evicted and reloaded, got %d loads", loads)
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	cache := NewLRULoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return 99, nil
+	}, 10, 20*time.Millisecond)
+
+	cache.Put("k", 1)
+	value, err := cache.Get("k")
+	if err != nil || value != 1 {
+		t.Fatalf("Get() = (%d, %v), want (1, nil)", value, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	value, err = cache.Get("k")
+	if err != nil || value != 99 {
+		t.Fatalf("Get() after expiry = (%d, %v), want (99, nil) from loadingFunc", value, err)
+	}
+}
+
+func TestLRUPurgeRemovesExpiredOnly(t *testing.T) {
+	cache := NewLRULoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return 0, nil
+	}, 10, 20*time.Millisecond)
+
+	cache.Put("stale", 1)
+	time.Sleep(30 * time.Millisecond)
+	cache.Put("fresh", 2)
+
+	cache.Purge()
+
+	if cache.Len() != 1 {
+		t.Fatalf("Len() after Purge = %d, want 1", cache.Len())
+	}
+	if _, ok := cache.store.Get("fresh"); !ok {
+		t.Fatalf("Purge removed the non-expired key")
+	}
+}
+
+func TestLRUSetLazilyPurgesExpiredEntries(t *testing.T) {
+	cache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}, 100, 10*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		cache.Put(i, i)
+	}
+	time.Sleep(20 * time.Millisecond) // all 5 now expired, nothing has read them since
+
+	// Each Put below should lazily sweep a few of the stale entries above,
+	// without anyone calling Purge.
+	for i := 100; i < 103; i++ {
+		cache.Put(i, i)
+	}
+
+	if got := cache.Len(); got >= 8 {
+		t.Fatalf("Len() = %d, want fewer than 8 (expired entries should have been swept on write)", got)
+	}
+}
+
+func TestLRUOnEvict(t *testing.T) {
+	type event struct {
+		key    int
+		reason EvictReason
+	}
+	events := make(chan event, 10)
+
+	cache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}, 1, time.Hour)
+	cache.OnEvict(func(key int, value int, reason EvictReason) {
+		events <- event{key, reason}
+	})
+
+	cache.Put(1, 1)
+	cache.Put(2, 2) // evicts 1
+
+	select {
+	case e := <-events:
+		if e.key != 1 || e.reason != EvictReasonEvicted {
+			t.Fatalf("got event %+v, want key=1 reason=EvictReasonEvicted", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnEvict was never called")
+	}
+
+	cache.Remove(2)
+	select {
+	case e := <-events:
+		if e.key != 2 || e.reason != EvictReasonRemoved {
+			t.Fatalf("got event %+v, want key=2 reason=EvictReasonRemoved", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnEvict was never called for Remove")
+	}
+}
+
+// TestLRUOnEvictReentrantPutDoesNotDeadlock guards against the bug where
+// removeElementLocked fired OnEvict/OnEvent while lruStore.mu (and
+// loadingCache.mu, for an eviction triggered from Put/GetContext) was still
+// held: a callback that called back into the same cache would hang forever.
+func TestLRUOnEvictReentrantPutDoesNotDeadlock(t *testing.T) {
+	done := make(chan struct{})
+
+	go func() {
+		cache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+			return key, nil
+		}, 2, time.Hour)
+
+		cache.OnEvict(func(key int, value int, reason EvictReason) {
+			if key < 50 {
+				cache.Put(key+100, value) // reentrant call from inside the eviction callback
+			}
+		})
+
+		cache.Put(1, 1)
+		cache.Put(2, 2)
+		cache.Put(3, 3) // evicts 1, which re-enters Put
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: OnEvict calling back into the cache never returned")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// refreshEntry is the unit stored by refreshLoadingCache. While ready is
+// false it is an in-flight placeholder, exactly like pendingLoad in
+// loadingCache. Once ready, loadedAt tracks its age so Get can decide whether
+// it is fresh, stale-but-usable, or hard-expired.
+type refreshEntry[V any] struct {
+	wg         sync.WaitGroup
+	ready      bool
+	value      V
+	err        error
+	loadedAt   time.Time
+	refreshing bool
+}
+
+// refreshLoadingCache implements stale-while-revalidate: a value younger than
+// freshFor is returned as-is, a value between freshFor and staleFor is
+// returned immediately while a background reload is kicked off, and only a
+// value older than staleFor blocks the caller on a synchronous reload.
+type refreshLoadingCache[K comparable, V any] struct {
+	mu          sync.Mutex
+	data        map[K]*refreshEntry[V]
+	loadingFunc func(context.Context, K) (V, error)
+	freshFor    time.Duration
+	staleFor    time.Duration
+	stats       cacheStats
+	onEvent     func(Event)
+}
+
+// NewLoadingCacheWithRefresh returns a Cache[K, V] that serves stale values
+// while refreshing them in the background, instead of blocking every caller
+// on a synchronous reload the instant a value expires.
+//
+// A value younger than freshFor is returned as-is. A value whose age is
+// between freshFor and staleFor is still returned, but a background refresh
+// is triggered; at most one refresh per key runs at a time, gated the same
+// way loadingCache.GetContext gates concurrent loads. A value older than
+// staleFor is treated as a miss and loaded synchronously, blocking the
+// caller.
+func NewLoadingCacheWithRefresh[K comparable, V any](loadingFunc func(context.Context, K) (V, error), freshFor time.Duration, staleFor time.Duration) *refreshLoadingCache[K, V] {
+	return &refreshLoadingCache[K, V]{
+		data:        map[K]*refreshEntry[V]{},
+		loadingFunc: loadingFunc,
+		freshFor:    freshFor,
+		staleFor:    staleFor,
+	}
+}
+
+func (l *refreshLoadingCache[K, V]) Get(key K) (V, error) {
+	return l.GetContext(context.Background(), key)
+}
+
+func (l *refreshLoadingCache[K, V]) GetContext(ctx context.Context, key K) (V, error) {
+	for {
+		l.mu.Lock()
+
+		entry, exists := l.data[key]
+		if exists && !entry.ready {
+			// another caller's synchronous load is in flight for this key
+			l.mu.Unlock()
+			if err := waitContext(ctx, &entry.wg); err != nil {
+				var zero V
+				return zero, err
+			}
+			continue
+		}
+
+		if exists && entry.ready {
+			age := time.Since(entry.loadedAt)
+			if age < l.freshFor {
+				value, err := entry.value, entry.err
+				l.mu.Unlock()
+				atomic.AddUint64(&l.stats.hits, 1)
+				l.emit(Event{Type: EventHit, Key: key})
+				return value, err
+			}
+			if age < l.staleFor {
+				if !entry.refreshing {
+					entry.refreshing = true
+					go l.refresh(key, entry)
+				}
+				value, err := entry.value, entry.err
+				l.mu.Unlock()
+				atomic.AddUint64(&l.stats.hits, 1)
+				l.emit(Event{Type: EventHit, Key: key})
+				return value, err
+			}
+			// older than staleFor: fall through and reload synchronously
+		}
+
+		missEvent := EventMiss
+		if exists {
+			missEvent = EventExpire
+		}
+
+		newEntry := &refreshEntry[V]{}
+		newEntry.wg.Add(1)
+		l.data[key] = newEntry
+		l.mu.Unlock()
+
+		if missEvent == EventExpire {
+			atomic.AddUint64(&l.stats.expirations, 1)
+		} else {
+			atomic.AddUint64(&l.stats.misses, 1)
+		}
+		l.emit(Event{Type: missEvent, Key: key})
+
+		loadStart := time.Now()
+		value, err := l.loadingFunc(ctx, key)
+		duration := time.Since(loadStart)
+
+		atomic.AddUint64(&l.stats.loads, 1)
+		l.stats.loadTimes.observe(duration)
+		l.emit(Event{Type: EventLoad, Key: key, Duration: duration})
+
+		l.mu.Lock()
+		newEntry.value = value
+		newEntry.err = err
+		newEntry.loadedAt = time.Now()
+		newEntry.ready = true
+		l.mu.Unlock()
+		newEntry.wg.Done()
+
+		if err != nil {
+			atomic.AddUint64(&l.stats.loadErrors, 1)
+			l.emit(Event{Type: EventLoadError, Key: key, Duration: duration})
+		}
+
+		return value, err
+	}
+}
+
+// refresh reloads entry's value in the background and updates it in place,
+// as long as entry is still the current value for key - it may have already
+// been replaced by a synchronous hard-expiry reload by the time this runs.
+//
+// loadingFunc runs through recoverableLoad rather than being called directly:
+// this is its own goroutine, so unlike a panic from the synchronous
+// GetContext path - which at least propagates to the caller that triggered it
+// - a panic here would have no caller to crash into except the whole
+// process.
+func (l *refreshLoadingCache[K, V]) refresh(key K, entry *refreshEntry[V]) {
+	loadStart := time.Now()
+	value, err := l.recoverableLoad(key)
+	duration := time.Since(loadStart)
+
+	l.mu.Lock()
+	if l.data[key] == entry && err == nil {
+		entry.value = value
+		entry.err = nil
+		entry.loadedAt = time.Now()
+	}
+	// on error (including a recovered panic) entry is left holding its last
+	// good value rather than being overwritten, so a caller already being
+	// served a stale result keeps getting it instead of a zero value; only
+	// refreshing is cleared, so a later Get can try again.
+	entry.refreshing = false
+	l.mu.Unlock()
+
+	atomic.AddUint64(&l.stats.loads, 1)
+	l.stats.loadTimes.observe(duration)
+	l.emit(Event{Type: EventLoad, Key: key, Duration: duration})
+	if err != nil {
+		atomic.AddUint64(&l.stats.loadErrors, 1)
+		l.emit(Event{Type: EventLoadError, Key: key, Duration: duration})
+	}
+}
+
+// recoverableLoad calls loadingFunc, converting a panic into an error instead
+// of letting it take down the process.
+func (l *refreshLoadingCache[K, V]) recoverableLoad(key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("refreshLoadingCache: loadingFunc panicked: %v", r)
+		}
+	}()
+	return l.loadingFunc(context.Background(), key)
+}
+
+func (l *refreshLoadingCache[K, V]) Put(key K, value V) {
+	l.mu.Lock()
+	l.data[key] = &refreshEntry[V]{
+		ready:    true,
+		value:    value,
+		loadedAt: time.Now(),
+	}
+	l.mu.Unlock()
+
+	atomic.AddUint64(&l.stats.puts, 1)
+	l.emit(Event{Type: EventPut, Key: key})
+}
+
+// OnEvent registers a hook called for every Hit, Miss, Expire, Load,
+// LoadError and Put. It replaces any previously registered hook.
+func (l *refreshLoadingCache[K, V]) OnEvent(onEvent func(Event)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onEvent = onEvent
+}
+
+func (l *refreshLoadingCache[K, V]) emit(event Event) {
+	if l.onEvent != nil {
+		l.onEvent(event)
+	}
+}
+
+// Stats returns a point-in-time snapshot of the cache's counters.
+func (l *refreshLoadingCache[K, V]) Stats() Stats {
+	l.mu.Lock()
+	size := len(l.data)
+	l.mu.Unlock()
+
+	return Stats{
+		Hits:                atomic.LoadUint64(&l.stats.hits),
+		Misses:              atomic.LoadUint64(&l.stats.misses),
+		Expirations:         atomic.LoadUint64(&l.stats.expirations),
+		Loads:               atomic.LoadUint64(&l.stats.loads),
+		LoadErrors:          atomic.LoadUint64(&l.stats.loadErrors),
+		Evictions:           atomic.LoadUint64(&l.stats.evictions),
+		Puts:                atomic.LoadUint64(&l.stats.puts),
+		Size:                size,
+		LoadDurationBuckets: l.stats.loadTimes.snapshot(),
+	}
+}
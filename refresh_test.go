@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshCacheServesFreshWithoutReloading(t *testing.T) {
+	var calls int64
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return int(atomic.LoadInt64(&calls)), nil
+	}, time.Hour, 2*time.Hour)
+
+	v1, _ := cache.Get("k")
+	v2, _ := cache.Get("k")
+
+	if v1 != v2 {
+		t.Fatalf("fresh Get returned different values: %d vs %d", v1, v2)
+	}
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Fatalf("loadingFunc called %d times for two fresh Gets, want 1", got)
+	}
+}
+
+func TestRefreshCacheServesStaleAndRefreshesInBackground(t *testing.T) {
+	var calls int64
+	done := make(chan struct{})
+
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if n == 2 {
+			close(done)
+		}
+		return int(n), nil
+	}, 20*time.Millisecond, time.Hour)
+
+	first, _ := cache.Get("k")
+	if first != 1 {
+		t.Fatalf("first Get() = %d, want 1", first)
+	}
+
+	time.Sleep(30 * time.Millisecond) // now stale (past freshFor, inside staleFor)
+
+	stale, err := cache.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stale != 1 {
+		t.Fatalf("Get() while stale = %d, want the old value 1 returned immediately", stale)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background refresh never ran")
+	}
+
+	// give the background refresh's write a moment to land, then confirm the
+	// refreshed value is now being served
+	var refreshed int
+	for i := 0; i < 100; i++ {
+		refreshed, _ = cache.Get("k")
+		if refreshed == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if refreshed != 2 {
+		t.Fatalf("Get() after background refresh = %d, want 2", refreshed)
+	}
+}
+
+func TestRefreshCacheHardExpiryBlocksAndReloads(t *testing.T) {
+	var calls int64
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		n := atomic.AddInt64(&calls, 1)
+		return int(n), nil
+	}, 5*time.Millisecond, 10*time.Millisecond)
+
+	cache.Get("k")
+	time.Sleep(20 * time.Millisecond) // past staleFor: hard-expired
+
+	value, err := cache.Get("k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != 2 {
+		t.Fatalf("Get() after hard expiry = %d, want 2 (synchronous reload)", value)
+	}
+}
+
+func TestRefreshCacheStatsAndOnEvent(t *testing.T) {
+	var mu sync.Mutex
+	var events []EventType
+
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		return 1, nil
+	}, time.Hour, 2*time.Hour)
+	cache.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e.Type)
+	})
+
+	cache.Get("k") // miss + load
+	cache.Get("k") // hit
+
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.Loads != 1 || stats.Hits != 1 {
+		t.Fatalf("Stats() = %+v, want Misses=1 Loads=1 Hits=1", stats)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []EventType{EventMiss, EventLoad, EventHit}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(events), events, want)
+	}
+	for i, w := range want {
+		if events[i] != w {
+			t.Fatalf("event[%d] = %v, want %v (all events: %v)", i, events[i], w, events)
+		}
+	}
+}
+
+func TestRefreshCacheBackgroundPanicDoesNotCrashAndKeepsStaleValue(t *testing.T) {
+	var calls int64
+	done := make(chan struct{})
+
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if n == 2 {
+			defer close(done)
+			panic("loader exploded")
+		}
+		return int(n), nil
+	}, 20*time.Millisecond, time.Hour)
+
+	first, _ := cache.Get("k")
+	if first != 1 {
+		t.Fatalf("first Get() = %d, want 1", first)
+	}
+
+	time.Sleep(30 * time.Millisecond) // stale: triggers the panicking background refresh
+
+	stale, err := cache.Get("k")
+	if err != nil || stale != 1 {
+		t.Fatalf("Get() while stale = (%d, %v), want (1, nil) served immediately", stale, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background refresh (that panics) never ran")
+	}
+
+	// give the panic recovery's write a moment to land
+	time.Sleep(10 * time.Millisecond)
+
+	afterPanic, err := cache.Get("k")
+	if err != nil || afterPanic != 1 {
+		t.Fatalf("Get() after a panicking background refresh = (%d, %v), want (1, nil): stale value preserved", afterPanic, err)
+	}
+
+	stats := cache.Stats()
+	if stats.LoadErrors != 1 {
+		t.Fatalf("LoadErrors = %d, want 1 for the recovered panic", stats.LoadErrors)
+	}
+}
+
+func TestRefreshCachePutOverridesLoader(t *testing.T) {
+	cache := NewLoadingCacheWithRefresh[string, int](func(ctx context.Context, key string) (int, error) {
+		return -1, nil
+	}, time.Hour, 2*time.Hour)
+
+	cache.Put("k", 7)
+
+	value, err := cache.Get("k")
+	if err != nil || value != 7 {
+		t.Fatalf("Get() = (%d, %v), want (7, nil)", value, err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"time"
+)
+
+// Hasher maps a key to a uint64 used to pick its shard. Keys that hash
+// equally don't need to be distributed evenly across the whole uint64 range,
+// just spread across the (much smaller) shard count.
+type Hasher[K comparable] func(key K) uint64
+
+// reflectHasher is the default Hasher used when none is supplied. It formats
+// the key with fmt and hashes the resulting bytes with maphash, which is slow
+// compared to a type-specific hash but works for any comparable K without the
+// caller having to write one.
+func reflectHasher[K comparable]() Hasher[K] {
+	var seed = maphash.MakeSeed()
+	return func(key K) uint64 {
+		var h maphash.Hash
+		h.SetSeed(seed)
+		fmt.Fprintf(&h, "%v", key)
+		return h.Sum64()
+	}
+}
+
+// shardedLoadingCache partitions keys across a fixed number of independent
+// loadingCache shards, each with its own map and mutex. A single loadingCache
+// serializes every key's load-in-progress bookkeeping behind one mutex, which
+// becomes a bottleneck once enough goroutines are hitting unrelated keys
+// concurrently; sharding lets those keys proceed independently at the cost of
+// a slightly worse LRU/memory bound per shard, since eviction (see
+// NewLRULoadingCache) would have to be sized and tuned per shard rather than
+// globally.
+//
+// This only pays off where mutex contention, not per-call overhead, is the
+// bottleneck: BenchmarkLoadingCache/BenchmarkShardedLoadingCache in
+// sharded_test.go (measured on a 2-core machine) show the default
+// reflect-based Hasher's fmt.Fprintf+maphash cost outweighing the reduced
+// contention at every goroutine count tried, up to 512. Expect sharding to
+// win instead on a machine with enough cores for the unsharded cache's single
+// mutex to actually queue goroutines, and to win by more once a cheaper,
+// type-specific Hasher replaces the default (see
+// NewShardedLoadingCacheWithHasher).
+type shardedLoadingCache[K comparable, V any] struct {
+	shards []*loadingCache[K, V]
+	hasher Hasher[K]
+}
+
+// NewShardedLoadingCache returns a Cache[K, V] split into shards independent
+// loadingCache instances, each guarded by its own lock, so lookups for keys
+// that land in different shards never contend with each other. Keys are
+// assigned to shards with a reflection-based hasher; use
+// NewShardedLoadingCacheWithHasher to supply a faster, type-specific one.
+func NewShardedLoadingCache[K comparable, V any](loadingFunc func(context.Context, K) (V, error), cacheDuration time.Duration, shards int) *shardedLoadingCache[K, V] {
+	return NewShardedLoadingCacheWithHasher(loadingFunc, cacheDuration, shards, reflectHasher[K]())
+}
+
+// NewShardedLoadingCacheWithHasher is NewShardedLoadingCache with an explicit
+// Hasher, for keys where formatting the key with fmt on every lookup is too slow or
+// where K's default formatting does not distribute well across shards.
+func NewShardedLoadingCacheWithHasher[K comparable, V any](loadingFunc func(context.Context, K) (V, error), cacheDuration time.Duration, shards int, hasher Hasher[K]) *shardedLoadingCache[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+
+	shardSlice := make([]*loadingCache[K, V], shards)
+	for i := range shardSlice {
+		shardSlice[i] = &loadingCache[K, V]{
+			pending:       map[K]*pendingLoad[V]{},
+			store:         newMemoryStore[K, V](),
+			loadingFunc:   loadingFunc,
+			cacheDuration: cacheDuration,
+		}
+	}
+
+	return &shardedLoadingCache[K, V]{
+		shards: shardSlice,
+		hasher: hasher,
+	}
+}
+
+func (s *shardedLoadingCache[K, V]) shardFor(key K) *loadingCache[K, V] {
+	return s.shards[s.hasher(key)%uint64(len(s.shards))]
+}
+
+func (s *shardedLoadingCache[K, V]) Get(key K) (V, error) {
+	return s.shardFor(key).Get(key)
+}
+
+func (s *shardedLoadingCache[K, V]) GetContext(ctx context.Context, key K) (V, error) {
+	return s.shardFor(key).GetContext(ctx, key)
+}
+
+func (s *shardedLoadingCache[K, V]) Put(key K, value V) {
+	s.shardFor(key).Put(key, value)
+}
+
+// OnEvent registers a hook called for every Hit, Miss, Expire, Load,
+// LoadError and Put across every shard. It replaces any previously
+// registered hook on each shard.
+func (s *shardedLoadingCache[K, V]) OnEvent(onEvent func(Event)) {
+	for _, shard := range s.shards {
+		shard.OnEvent(onEvent)
+	}
+}
+
+// Stats returns a point-in-time snapshot of the cache's counters, summed
+// across every shard. LoadDurationBuckets is merged bucket-by-bucket, same as
+// each shard's own histogram would report if the cache weren't sharded at
+// all.
+func (s *shardedLoadingCache[K, V]) Stats() Stats {
+	total := Stats{LoadDurationBuckets: map[float64]uint64{}}
+	for _, shard := range s.shards {
+		shardStats := shard.Stats()
+		total.Hits += shardStats.Hits
+		total.Misses += shardStats.Misses
+		total.Expirations += shardStats.Expirations
+		total.Loads += shardStats.Loads
+		total.LoadErrors += shardStats.LoadErrors
+		total.Evictions += shardStats.Evictions
+		total.Puts += shardStats.Puts
+		total.Size += shardStats.Size
+		for bound, count := range shardStats.LoadDurationBuckets {
+			total.LoadDurationBuckets[bound] += count
+		}
+	}
+	return total
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchmarkConcurrentGet hits cache.Get from a fixed number of goroutines,
+// each doing its share of b.N total calls across a small fixed set of keys -
+// enough keys that a sharded cache actually spreads them across shards, few
+// enough that a single loadingCache sees real contention on its one mutex.
+func benchmarkConcurrentGet(b *testing.B, cache Cache[int, int], goroutines int) {
+	const keySpace = 64
+
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			defer wg.Done()
+			calls := b.N / goroutines
+			if g < b.N%goroutines {
+				calls++
+			}
+			for i := 0; i < calls; i++ {
+				_, _ = cache.Get((g + i) % keySpace)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// noopLoader stands in for a cheap loadingFunc so these benchmarks measure
+// lock contention in Get, not loader latency.
+func noopLoader(_ context.Context, key int) (int, error) {
+	return key, nil
+}
+
+// BenchmarkLoadingCache and BenchmarkShardedLoadingCache measure Get
+// throughput at 1/8/64/512 concurrent goroutines, per the tradeoff documented
+// on shardedLoadingCache in sharded.go.
+func BenchmarkLoadingCache(b *testing.B) {
+	for _, goroutines := range []int{1, 8, 64, 512} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			cache := NewLoadingCache[int, int](noopLoader, time.Minute)
+			benchmarkConcurrentGet(b, cache, goroutines)
+		})
+	}
+}
+
+func TestShardedLoadingCacheStatsAndOnEvent(t *testing.T) {
+	var mu sync.Mutex
+	var events []EventType
+
+	cache := NewShardedLoadingCache[int, int](noopLoader, time.Minute, 4)
+	cache.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e.Type)
+	})
+
+	for key := 0; key < 8; key++ {
+		cache.Get(key) // miss + load, spread across shards by key
+	}
+	for key := 0; key < 8; key++ {
+		cache.Get(key) // hit
+	}
+
+	stats := cache.Stats()
+	if stats.Misses != 8 {
+		t.Fatalf("Misses = %d, want 8 (summed across shards)", stats.Misses)
+	}
+	if stats.Loads != 8 {
+		t.Fatalf("Loads = %d, want 8", stats.Loads)
+	}
+	if stats.Hits != 8 {
+		t.Fatalf("Hits = %d, want 8", stats.Hits)
+	}
+	if stats.Size != 8 {
+		t.Fatalf("Size = %d, want 8", stats.Size)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 24 { // 8 miss + 8 load + 8 hit, one shard's OnEvent per call
+		t.Fatalf("got %d events, want 24 (8 miss + 8 load + 8 hit)", len(events))
+	}
+}
+
+func BenchmarkShardedLoadingCache(b *testing.B) {
+	for _, goroutines := range []int{1, 8, 64, 512} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			cache := NewShardedLoadingCache[int, int](noopLoader, time.Minute, 16)
+			benchmarkConcurrentGet(b, cache, goroutines)
+		})
+	}
+}
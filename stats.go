@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies what happened to a key in a loadingCache, passed to an
+// OnEvent hook.
+type EventType int
+
+const (
+	// EventHit means Get found a live, unexpired value and returned it
+	// without calling loadingFunc. A loadingFunc error still held by
+	// NewLoadingCacheWithNegativeTTL is reported as a hit as well.
+	EventHit EventType = iota
+	// EventMiss means Get found no value at all for the key.
+	EventMiss
+	// EventExpire means Get found a value for the key, but it had passed its
+	// cacheDuration, so it was treated like a miss. An LRU cache also fires
+	// it when it purges an expired entry from its store.
+	EventExpire
+	// EventLoad means loadingFunc was called for the key. Duration is
+	// populated once the call returns.
+	EventLoad
+	// EventLoadError means loadingFunc returned an error for the key.
+	EventLoadError
+	// EventEvict means the key was evicted to make room, e.g. by an LRU
+	// cache's maxEntries bound.
+	EventEvict
+	// EventPut means the key was written via Put rather than loadingFunc.
+	EventPut
+)
+
+// Event is a single thing that happened to a key, delivered to an OnEvent
+// hook. Key is interface{} rather than a generic K so that OnEvent, Stats and
+// the Prometheus adapter below don't have to be parameterized by K or V.
+type Event struct {
+	Type     EventType
+	Key      interface{}
+	Duration time.Duration // populated for EventLoad and EventLoadError
+}
+
+// numLoadDurationBuckets must match len(loadDurationBuckets); Go array sizes
+// have to be compile-time constants, so it can't just be derived from the
+// slice below.
+const numLoadDurationBuckets = 7
+
+// loadDurationBuckets are the upper bounds (inclusive) of the load-duration
+// histogram reported by Stats, in the style of a Prometheus histogram: the
+// last bucket has no upper bound and catches everything slower than 1s.
+var loadDurationBuckets = [numLoadDurationBuckets]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// loadHistogram is a set of atomic counters, one per bucket in
+// loadDurationBuckets plus one overflow bucket for anything slower than the
+// last bound.
+type loadHistogram struct {
+	buckets [numLoadDurationBuckets + 1]uint64
+}
+
+func (h *loadHistogram) observe(d time.Duration) {
+	for i, bound := range loadDurationBuckets {
+		if d <= bound {
+			atomic.AddUint64(&h.buckets[i], 1)
+			return
+		}
+	}
+	atomic.AddUint64(&h.buckets[len(loadDurationBuckets)], 1)
+}
+
+// snapshot returns the bucket totals keyed by their upper bound in seconds,
+// using +Inf for the overflow bucket, matching how a Prometheus histogram
+// labels its "le" buckets.
+func (h *loadHistogram) snapshot() map[float64]uint64 {
+	out := make(map[float64]uint64, len(loadDurationBuckets)+1)
+	for i, bound := range loadDurationBuckets {
+		out[bound.Seconds()] = atomic.LoadUint64(&h.buckets[i])
+	}
+	out[math.Inf(1)] = atomic.LoadUint64(&h.buckets[len(loadDurationBuckets)])
+	return out
+}
+
+// cacheStats holds the atomic counters backing loadingCache.Stats. It is
+// embedded by value so zero-value loadingCache counters start at zero.
+type cacheStats struct {
+	hits        uint64
+	misses      uint64
+	expirations uint64
+	loads       uint64
+	loadErrors  uint64
+	evictions   uint64
+	puts        uint64
+	loadTimes   loadHistogram
+}
+
+// Stats is a point-in-time snapshot of a loadingCache's counters, returned by
+// Stats(). It exists so that callers can tune cacheDuration or diagnose a
+// thundering-herd loader without the cache being a total black box.
+type Stats struct {
+	Hits                uint64
+	Misses              uint64
+	Expirations         uint64
+	Loads               uint64
+	LoadErrors          uint64
+	Evictions           uint64
+	Puts                uint64
+	Size                int
+	LoadDurationBuckets map[float64]uint64 // upper bound in seconds -> count, +Inf for overflow
+}
+
+// PrometheusMetrics mirrors Stats in the float64 shape a
+// github.com/prometheus/client_golang collector expects, so callers can wire
+// a loadingCache into Prometheus without this package importing that client.
+type PrometheusMetrics struct {
+	Hits                float64
+	Misses              float64
+	Expirations         float64
+	Loads               float64
+	LoadErrors          float64
+	Evictions           float64
+	Puts                float64
+	Size                float64
+	LoadDurationBuckets map[float64]float64
+}
+
+// Prometheus converts s to the float64 shape expected by a Prometheus
+// collector, e.g. to populate a prometheus.Gauge/Histogram pair.
+func (s Stats) Prometheus() PrometheusMetrics {
+	buckets := make(map[float64]float64, len(s.LoadDurationBuckets))
+	for bound, count := range s.LoadDurationBuckets {
+		buckets[bound] = float64(count)
+	}
+
+	return PrometheusMetrics{
+		Hits:                float64(s.Hits),
+		Misses:              float64(s.Misses),
+		Expirations:         float64(s.Expirations),
+		Loads:               float64(s.Loads),
+		LoadErrors:          float64(s.LoadErrors),
+		Evictions:           float64(s.Evictions),
+		Puts:                float64(s.Puts),
+		Size:                float64(s.Size),
+		LoadDurationBuckets: buckets,
+	}
+}
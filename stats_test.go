@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsCountsHitsMissesLoadsAndErrors(t *testing.T) {
+	wantErr := errors.New("boom")
+	fail := false
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		if fail {
+			return 0, wantErr
+		}
+		return 1, nil
+	}, time.Hour).(*loadingCache[string, int])
+
+	cache.Get("a") // miss + load
+	cache.Get("a") // hit
+
+	fail = true
+	cache.Get("b") // miss + load + loadError
+
+	stats := cache.Stats()
+	if stats.Misses != 2 {
+		t.Fatalf("Misses = %d, want 2", stats.Misses)
+	}
+	if stats.Hits != 1 {
+		t.Fatalf("Hits = %d, want 1", stats.Hits)
+	}
+	if stats.Loads != 2 {
+		t.Fatalf("Loads = %d, want 2", stats.Loads)
+	}
+	if stats.LoadErrors != 1 {
+		t.Fatalf("LoadErrors = %d, want 1", stats.LoadErrors)
+	}
+	if stats.Size != 1 {
+		t.Fatalf("Size = %d, want 1 (the failed load was never stored)", stats.Size)
+	}
+}
+
+func TestStatsCountsPutsAndExpirations(t *testing.T) {
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return 1, nil
+	}, 10*time.Millisecond).(*loadingCache[string, int])
+
+	cache.Put("a", 1)
+	cache.Get("a") // fresh, hit
+
+	time.Sleep(20 * time.Millisecond)
+	cache.Get("a") // expired, reloads
+
+	stats := cache.Stats()
+	if stats.Puts != 1 {
+		t.Fatalf("Puts = %d, want 1", stats.Puts)
+	}
+	if stats.Expirations != 1 {
+		t.Fatalf("Expirations = %d, want 1", stats.Expirations)
+	}
+}
+
+func TestOnEventFiresForHitMissAndLoad(t *testing.T) {
+	cache := NewLoadingCache[string, int](func(ctx context.Context, key string) (int, error) {
+		return 1, nil
+	}, time.Hour).(*loadingCache[string, int])
+
+	var mu sync.Mutex
+	var types []EventType
+	cache.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		types = append(types, e.Type)
+	})
+
+	cache.Get("a") // miss, load
+	cache.Get("a") // hit
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []EventType{EventMiss, EventLoad, EventHit}
+	if len(types) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(types), types, want)
+	}
+	for i, w := range want {
+		if types[i] != w {
+			t.Fatalf("event[%d] = %v, want %v (all events: %v)", i, types[i], w, types)
+		}
+	}
+}
+
+func TestStatsCountsLRUEvictions(t *testing.T) {
+	cache := NewLRULoadingCache[int, int](func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}, 1, time.Hour)
+
+	cache.Put(1, 1)
+	cache.Put(2, 2) // evicts 1
+
+	stats := cache.Stats()
+	if stats.Evictions != 1 {
+		t.Fatalf("Evictions = %d, want 1", stats.Evictions)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import "sync"
+
+// Store is the storage concern behind a loadingCache, holding already-loaded
+// values so that loadingCache itself only has to worry about the
+// loading/singleflight logic. The default is an in-memory memoryStore, but a
+// Store can just as well be backed by Redis or a file on disk.
+type Store[K comparable, V any] interface {
+	Get(key K) (CacheValue[V], bool)
+	Set(key K, value CacheValue[V])
+	Delete(key K)
+	// Iterate calls fn for every entry in the store, stopping early if fn
+	// returns false.
+	Iterate(fn func(K, CacheValue[V]) bool)
+}
+
+// KeyFunc converts a cache key to a string. Store implementations that can
+// only address entries by string key (e.g. a Redis- or file-backed Store)
+// take one of these so they can support a non-string K.
+type KeyFunc[K any] func(key K) string
+
+// memoryStore is the default Store, backed by a single map guarded by a
+// RWMutex.
+type memoryStore[K comparable, V any] struct {
+	mu   sync.RWMutex
+	data map[K]CacheValue[V]
+}
+
+func newMemoryStore[K comparable, V any]() *memoryStore[K, V] {
+	return &memoryStore[K, V]{data: map[K]CacheValue[V]{}}
+}
+
+func (s *memoryStore[K, V]) Get(key K) (CacheValue[V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, exists := s.data[key]
+	return value, exists
+}
+
+func (s *memoryStore[K, V]) Set(key K, value CacheValue[V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+}
+
+func (s *memoryStore[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+}
+
+func (s *memoryStore[K, V]) Iterate(fn func(K, CacheValue[V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, value := range s.data {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of every entry currently in the store, suitable for
+// persisting somewhere and later handing to Restore to warm-start a new
+// process instead of starting with an empty cache.
+func (s *memoryStore[K, V]) Snapshot() map[K]CacheValue[V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := make(map[K]CacheValue[V], len(s.data))
+	for key, value := range s.data {
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+// Restore replaces the store's contents with snapshot, as produced by a
+// prior call to Snapshot.
+func (s *memoryStore[K, V]) Restore(snapshot map[K]CacheValue[V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = make(map[K]CacheValue[V], len(snapshot))
+	for key, value := range snapshot {
+		s.data[key] = value
+	}
+}
+
+// keyedValue is what stringKeyedStore indexes by string key: the original K
+// alongside its CacheValue[V], so Iterate can still hand back (K,
+// CacheValue[V]) pairs even though the map underneath is keyed by string.
+type keyedValue[K any, V any] struct {
+	key   K
+	value CacheValue[V]
+}
+
+// stringKeyedStore is a Store[K, V] that addresses entries by string key via
+// a KeyFunc, for the common case of a backend that only understands string
+// keys - a Redis SET/GET or a filename on disk - rather than an arbitrary
+// comparable K. It's in-memory rather than actually talking to Redis or
+// disk, but it's the reference shape such a Store takes: everything routes
+// through keyFunc(key) before touching the underlying map.
+type stringKeyedStore[K comparable, V any] struct {
+	mu      sync.RWMutex
+	keyFunc KeyFunc[K]
+	data    map[string]keyedValue[K, V]
+}
+
+// NewStringKeyedStore returns a Store[K, V] that converts K to a string via
+// keyFunc before storing or looking up an entry, for plugging in a backend
+// that can only address entries by string key.
+func NewStringKeyedStore[K comparable, V any](keyFunc KeyFunc[K]) Store[K, V] {
+	return &stringKeyedStore[K, V]{
+		keyFunc: keyFunc,
+		data:    map[string]keyedValue[K, V]{},
+	}
+}
+
+func (s *stringKeyedStore[K, V]) Get(key K) (CacheValue[V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, exists := s.data[s.keyFunc(key)]
+	return entry.value, exists
+}
+
+func (s *stringKeyedStore[K, V]) Set(key K, value CacheValue[V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[s.keyFunc(key)] = keyedValue[K, V]{key: key, value: value}
+}
+
+func (s *stringKeyedStore[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, s.keyFunc(key))
+}
+
+func (s *stringKeyedStore[K, V]) Iterate(fn func(K, CacheValue[V]) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.data {
+		if !fn(entry.key, entry.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSnapshotRestore(t *testing.T) {
+	store := newMemoryStore[string, int]()
+	store.Set("a", CacheValue[int]{Value: 1, Expiration: time.Now().Add(time.Hour)})
+	store.Set("b", CacheValue[int]{Value: 2, Expiration: time.Now().Add(time.Hour)})
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("Snapshot() has %d entries, want 2", len(snapshot))
+	}
+
+	restored := newMemoryStore[string, int]()
+	restored.Restore(snapshot)
+
+	for _, key := range []string{"a", "b"} {
+		want, _ := store.Get(key)
+		got, ok := restored.Get(key)
+		if !ok {
+			t.Fatalf("restored store missing key %q", key)
+		}
+		if got.Value != want.Value {
+			t.Fatalf("restored[%q] = %d, want %d", key, got.Value, want.Value)
+		}
+	}
+
+	// Snapshot is a copy: mutating the live store afterward must not affect it
+	store.Set("a", CacheValue[int]{Value: 99, Expiration: time.Now().Add(time.Hour)})
+	if snapshot["a"].Value != 1 {
+		t.Fatalf("Snapshot was not a copy: snapshot[\"a\"] = %d, want 1", snapshot["a"].Value)
+	}
+}
+
+func TestMemoryStoreDeleteAndIterate(t *testing.T) {
+	store := newMemoryStore[string, int]()
+	store.Set("a", CacheValue[int]{Value: 1})
+	store.Set("b", CacheValue[int]{Value: 2})
+	store.Delete("a")
+
+	seen := map[string]int{}
+	store.Iterate(func(key string, value CacheValue[int]) bool {
+		seen[key] = value.Value
+		return true
+	})
+
+	if len(seen) != 1 || seen["b"] != 2 {
+		t.Fatalf("Iterate() saw %v, want only {b: 2}", seen)
+	}
+}
+
+func TestStringKeyedStore(t *testing.T) {
+	keyFunc := func(key int) string { return fmt.Sprintf("key-%d", key) }
+	store := NewStringKeyedStore[int, string](keyFunc)
+
+	store.Set(1, CacheValue[string]{Value: "one"})
+	store.Set(2, CacheValue[string]{Value: "two"})
+
+	value, ok := store.Get(1)
+	if !ok || value.Value != "one" {
+		t.Fatalf("Get(1) = (%q, %v), want (\"one\", true)", value.Value, ok)
+	}
+
+	store.Delete(1)
+	if _, ok := store.Get(1); ok {
+		t.Fatalf("Get(1) after Delete should report not found")
+	}
+
+	seen := map[int]string{}
+	store.Iterate(func(key int, value CacheValue[string]) bool {
+		seen[key] = value.Value
+		return true
+	})
+	if len(seen) != 1 || seen[2] != "two" {
+		t.Fatalf("Iterate() saw %v, want only {2: \"two\"}", seen)
+	}
+}